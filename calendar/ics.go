@@ -0,0 +1,148 @@
+// Package calendar renders the duty schedule as an RFC 5545 iCalendar feed,
+// so that it can be subscribed to from Google Calendar, Outlook and the
+// like instead of being polled through the JSON API.
+package calendar
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a single day of duty, as needed to render a VEVENT. Callers
+// translate their own `master`/`duty` values into this shape rather than
+// package calendar importing package main directly.
+type Event struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+const dateFormat = "20060102"
+
+// Render serializes `events` into a VCALENDAR containing one all-day VEVENT
+// per entry, sorted by date for a stable, readable feed.
+func Render(events []Event) string {
+	sorted := make([]Event, len(events))
+	copy(sorted, events)
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Date.Before(sorted[j].Date)
+	})
+
+	var lines []string
+
+	lines = append(lines,
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//cake//duty schedule//EN",
+		"CALSCALE:GREGORIAN",
+		"X-WR-CALNAME:On-duty schedule",
+	)
+
+	for _, event := range sorted {
+		lines = append(lines, renderEvent(event)...)
+	}
+
+	lines = append(lines, "END:VCALENDAR")
+
+	var out strings.Builder
+	for _, line := range lines {
+		out.WriteString(fold(line))
+		out.WriteString("\r\n")
+	}
+
+	return out.String()
+}
+
+func renderEvent(event Event) []string {
+	dtstart := event.Date.Format(dateFormat)
+	dtend := event.Date.AddDate(0, 0, 1).Format(dateFormat)
+
+	lines := []string{
+		"BEGIN:VEVENT",
+		"UID:" + uid(event),
+		"DTSTAMP:" + time.Now().UTC().Format("20060102T150405Z"),
+		"DTSTART;VALUE=DATE:" + dtstart,
+		"DTEND;VALUE=DATE:" + dtend,
+		"SUMMARY:On-duty: " + escape(event.Name),
+	}
+
+	if event.Email != "" {
+		lines = append(lines, fmt.Sprintf(
+			"ORGANIZER;CN=%s:mailto:%s", escape(event.Name), event.Email,
+		))
+	}
+
+	lines = append(lines, "END:VEVENT")
+
+	return lines
+}
+
+// uid derives a UID that's stable across regenerations of the same
+// schedule: it depends only on the person's name and the duty date, not on
+// anything that changes between runs (timestamps, slice order, ...).
+func uid(event Event) string {
+	sum := sha1.Sum([]byte(event.Name + "|" + event.Date.Format(dateFormat)))
+
+	return fmt.Sprintf("%x@cake", sum)
+}
+
+func escape(value string) string {
+	replacer := strings.NewReplacer(
+		`\`, `\\`,
+		`,`, `\,`,
+		`;`, `\;`,
+		"\n", `\n`,
+	)
+
+	return replacer.Replace(value)
+}
+
+// fold wraps a content line at 75 octets as required by RFC 5545 section
+// 3.1: continuation lines start with a single space, which itself counts
+// against the 75-octet limit, so continuation chunks carry one less octet
+// of content than the first line. Names come from a Confluence page edited
+// by people, not just ASCII input, so the split point is backed off to the
+// nearest rune boundary instead of cutting a multi-byte UTF-8 character in
+// half.
+func fold(line string) string {
+	const limit = 75
+
+	if len(line) <= limit {
+		return line
+	}
+
+	var folded strings.Builder
+
+	first := true
+
+	for len(line) > 0 {
+		chunk := limit
+		if !first {
+			chunk--
+		}
+
+		if chunk > len(line) {
+			chunk = len(line)
+		}
+
+		for chunk < len(line) && chunk > 0 && !utf8.RuneStart(line[chunk]) {
+			chunk--
+		}
+
+		if !first {
+			folded.WriteString("\r\n ")
+		}
+
+		folded.WriteString(line[:chunk])
+		line = line[chunk:]
+
+		first = false
+	}
+
+	return folded.String()
+}
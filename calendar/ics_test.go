@@ -0,0 +1,105 @@
+package calendar
+
+import (
+	"testing"
+	"unicode/utf8"
+)
+
+func TestFold(t *testing.T) {
+	cases := []struct {
+		name string
+		in   string
+	}{
+		{name: "short line is untouched", in: "SUMMARY:On-duty: Alice"},
+		{name: "exactly at the limit", in: "SUMMARY:" + repeat("a", 75-len("SUMMARY:"))},
+		{name: "one over the limit", in: "SUMMARY:" + repeat("a", 76-len("SUMMARY:"))},
+		{name: "several lines worth of content", in: "SUMMARY:" + repeat("a", 300)},
+		{
+			name: "non-ASCII name crossing the limit",
+			in:   "SUMMARY:On-duty: " + repeat("Иванов Иван Иванович ", 5),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			folded := fold(c.in)
+
+			for _, physical := range splitCRLF(folded) {
+				if len(physical) > 75 {
+					t.Fatalf(
+						"physical line %q is %d octets, want <= 75",
+						physical, len(physical),
+					)
+				}
+
+				if !utf8.ValidString(physical) {
+					t.Fatalf("physical line %q is not valid UTF-8", physical)
+				}
+			}
+
+			if unfold(folded) != c.in {
+				t.Fatalf("unfold(fold(in)) = %q, want %q", unfold(folded), c.in)
+			}
+		})
+	}
+}
+
+func TestEscape(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{in: "Alice", want: "Alice"},
+		{in: `back\slash`, want: `back\\slash`},
+		{in: "a, b", want: `a\, b`},
+		{in: "a; b", want: `a\; b`},
+		{in: "line1\nline2", want: `line1\nline2`},
+	}
+
+	for _, c := range cases {
+		got := escape(c.in)
+		if got != c.want {
+			t.Errorf("escape(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func repeat(s string, n int) string {
+	out := make([]byte, 0, n*len(s))
+	for i := 0; i < n; i++ {
+		out = append(out, s...)
+	}
+
+	return string(out)
+}
+
+// splitCRLF splits a folded content line back into its physical lines.
+func splitCRLF(s string) []string {
+	var lines []string
+	start := 0
+
+	for i := 0; i+1 < len(s); i++ {
+		if s[i] == '\r' && s[i+1] == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 2
+			i++
+		}
+	}
+
+	lines = append(lines, s[start:])
+
+	return lines
+}
+
+// unfold reverses fold: strip the CRLF + leading space inserted at each
+// continuation boundary to recover the original, unfolded content line.
+func unfold(s string) string {
+	physical := splitCRLF(s)
+
+	out := physical[0]
+	for _, line := range physical[1:] {
+		out += line[1:]
+	}
+
+	return out
+}
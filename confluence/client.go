@@ -0,0 +1,188 @@
+// Package confluence fetches and caches the Confluence page that contains
+// the duty schedule table, honoring the server's ETag/Last-Modified
+// validators so an unchanged page is served from cache instead of being
+// re-downloaded and re-parsed on every request.
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/seletskiy/cake/metrics"
+)
+
+// FetchError is returned when the Confluence request itself failed, or
+// returned a status other than 2xx/304, so callers can tell transport
+// failures apart from "nothing changed" and respond with the right HTTP
+// status of their own.
+type FetchError struct {
+	StatusCode int
+	Err        error
+}
+
+func (err *FetchError) Error() string {
+	if err.StatusCode != 0 {
+		return fmt.Sprintf(
+			"confluence request failed with status %d: %s",
+			err.StatusCode, err.Err,
+		)
+	}
+
+	return fmt.Sprintf("confluence request failed: %s", err.Err)
+}
+
+func (err *FetchError) Unwrap() error {
+	return err.Err
+}
+
+type cacheEntry struct {
+	body         string
+	etag         string
+	lastModified string
+}
+
+// Client fetches a single Confluence article and caches its body in
+// memory, re-validating with the server rather than blindly re-downloading
+// it on every call to Get.
+type Client struct {
+	URL      string
+	Login    string
+	Password string
+
+	httpClient *http.Client
+
+	mu    sync.RWMutex
+	cache *cacheEntry
+}
+
+// NewClient returns a client for the article at url, authenticating with
+// basic auth.
+func NewClient(url, login, password string) *Client {
+	return &Client{
+		URL:        url,
+		Login:      login,
+		Password:   password,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Get returns the current page body, either freshly fetched or served from
+// cache if Confluence reports nothing changed (HTTP 304), along with
+// whether the body is different from the one returned by the previous
+// call. The previous call may have been made by any caller, Refresh
+// included, so a change already reported to one caller is reported as
+// unchanged to the next.
+func (client *Client) Get(ctx context.Context) (string, bool, error) {
+	start := time.Now()
+	defer func() {
+		metrics.FetchDuration.Observe(time.Since(start).Seconds())
+	}()
+
+	request, err := http.NewRequestWithContext(
+		ctx, "GET", client.URL+`?expand=body.storage`, nil,
+	)
+	if err != nil {
+		metrics.FetchesTotal.WithLabelValues("error").Inc()
+		return "", false, &FetchError{Err: err}
+	}
+
+	request.SetBasicAuth(client.Login, client.Password)
+
+	client.mu.RLock()
+	cached := client.cache
+	client.mu.RUnlock()
+
+	if cached != nil {
+		if cached.etag != "" {
+			request.Header.Set("If-None-Match", cached.etag)
+		}
+
+		if cached.lastModified != "" {
+			request.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		metrics.FetchesTotal.WithLabelValues("error").Inc()
+		return "", false, &FetchError{Err: err}
+	}
+	defer response.Body.Close()
+
+	metrics.FetchesTotal.WithLabelValues(strconv.Itoa(response.StatusCode)).Inc()
+
+	if response.StatusCode == http.StatusNotModified && cached != nil {
+		return cached.body, false, nil
+	}
+
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return "", false, &FetchError{
+			StatusCode: response.StatusCode,
+			Err:        fmt.Errorf("unexpected status %s", response.Status),
+		}
+	}
+
+	raw, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", false, &FetchError{Err: err}
+	}
+
+	article := struct {
+		Body struct {
+			Storage struct {
+				Value string
+			}
+		}
+	}{}
+
+	if err := json.Unmarshal(raw, &article); err != nil {
+		return "", false, &FetchError{Err: err}
+	}
+
+	entry := &cacheEntry{
+		body:         article.Body.Storage.Value,
+		etag:         response.Header.Get("ETag"),
+		lastModified: response.Header.Get("Last-Modified"),
+	}
+
+	changed := cached == nil || cached.body != entry.body
+
+	client.mu.Lock()
+	client.cache = entry
+	client.mu.Unlock()
+
+	return entry.body, changed, nil
+}
+
+// Refresh runs in the background, re-validating the cached page every
+// interval (plus up to `jitter` of random slack, so that multiple daemons
+// don't all hit Confluence in lockstep), until ctx is cancelled.
+func (client *Client) Refresh(ctx context.Context, interval, jitter time.Duration) {
+	for {
+		wait := interval
+		if jitter > 0 {
+			wait += time.Duration(rand.Int63n(int64(jitter)))
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+
+		case <-timer.C:
+			if _, _, err := client.Get(ctx); err != nil {
+				log.Printf("confluence: background refresh failed: %s", err)
+			}
+		}
+	}
+}
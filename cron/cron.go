@@ -0,0 +1,132 @@
+// Package cron implements a minimal standard 5-field cron expression
+// (minute hour day-of-month month day-of-week) parser, shared by the
+// `notify` and `task` schedulers so that neither has to re-implement it.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed cron expression that can tell the next instant it
+// matches.
+type Schedule struct {
+	minute  field
+	hour    field
+	dom     field
+	month   field
+	weekday field
+}
+
+// field is the set of values a single cron field matches, or nil if the
+// field is "*" (matches anything).
+type field map[int]bool
+
+// Parse parses a standard 5-field cron expression.
+func Parse(expr string) (*Schedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf(
+			"cron expression %q must have 5 fields, got %d",
+			expr, len(fields),
+		)
+	}
+
+	parsed := make([]field, 5)
+	bounds := [5][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 6}}
+
+	for i, part := range fields {
+		value, err := parseField(part, bounds[i][0], bounds[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("cron field %q: %s", part, err)
+		}
+
+		parsed[i] = value
+	}
+
+	return &Schedule{
+		minute:  parsed[0],
+		hour:    parsed[1],
+		dom:     parsed[2],
+		month:   parsed[3],
+		weekday: parsed[4],
+	}, nil
+}
+
+func parseField(part string, min, max int) (field, error) {
+	if part == "*" {
+		return nil, nil
+	}
+
+	result := field{}
+
+	for _, chunk := range strings.Split(part, ",") {
+		step := 1
+
+		if strings.Contains(chunk, "/") {
+			pieces := strings.SplitN(chunk, "/", 2)
+			chunk = pieces[0]
+
+			parsedStep, err := strconv.Atoi(pieces[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid step %q", pieces[1])
+			}
+
+			step = parsedStep
+		}
+
+		lo, hi := min, max
+
+		if chunk != "*" {
+			bounds := strings.SplitN(chunk, "-", 2)
+
+			value, err := strconv.Atoi(bounds[0])
+			if err != nil {
+				return nil, fmt.Errorf("invalid value %q", bounds[0])
+			}
+
+			lo, hi = value, value
+
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid value %q", bounds[1])
+				}
+			}
+		}
+
+		for value := lo; value <= hi; value += step {
+			result[value] = true
+		}
+	}
+
+	return result, nil
+}
+
+func (f field) matches(value int) bool {
+	return f == nil || f[value]
+}
+
+// Next returns the first minute-aligned instant strictly after `after`
+// that satisfies the schedule. Only the following 366 days are searched;
+// if nothing in them matches, the instant 366 days later is returned even
+// though it does not match.
+func (schedule *Schedule) Next(after time.Time) time.Time {
+	candidate := after.Truncate(time.Minute).Add(time.Minute)
+
+	for i := 0; i < 366*24*60; i++ {
+		if schedule.minute.matches(candidate.Minute()) &&
+			schedule.hour.matches(candidate.Hour()) &&
+			schedule.dom.matches(candidate.Day()) &&
+			schedule.month.matches(int(candidate.Month())) &&
+			schedule.weekday.matches(int(candidate.Weekday())) {
+			return candidate
+		}
+
+		candidate = candidate.Add(time.Minute)
+	}
+
+	return candidate
+}
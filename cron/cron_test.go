@@ -0,0 +1,103 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseField(t *testing.T) {
+	cases := []struct {
+		name    string
+		expr    string
+		wantErr bool
+	}{
+		{name: "star", expr: "* * * * *"},
+		{name: "exact minute", expr: "30 * * * *"},
+		{name: "list", expr: "0,15,30,45 * * * *"},
+		{name: "range", expr: "0 9-17 * * *"},
+		{name: "step", expr: "*/15 * * * *"},
+		{name: "range with step", expr: "0 9-17/2 * * *"},
+		{name: "weekdays", expr: "0 9 * * 1-5"},
+		{name: "too few fields", expr: "* * * *", wantErr: true},
+		{name: "too many fields", expr: "* * * * * *", wantErr: true},
+		{name: "invalid value", expr: "sixty * * * *", wantErr: true},
+		{name: "invalid step", expr: "*/x * * * *", wantErr: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Parse(c.expr)
+			if c.wantErr && err == nil {
+				t.Fatalf("Parse(%q) = nil error, want error", c.expr)
+			}
+
+			if !c.wantErr && err != nil {
+				t.Fatalf("Parse(%q) = %s, want no error", c.expr, err)
+			}
+		})
+	}
+}
+
+func TestScheduleNext(t *testing.T) {
+	cases := []struct {
+		name  string
+		expr  string
+		after string
+		want  string
+	}{
+		{
+			name:  "every minute",
+			expr:  "* * * * *",
+			after: "2026-01-01T00:00:00Z",
+			want:  "2026-01-01T00:01:00Z",
+		},
+		{
+			name:  "daily at 09:00 same day",
+			expr:  "0 9 * * *",
+			after: "2026-01-01T00:00:00Z",
+			want:  "2026-01-01T09:00:00Z",
+		},
+		{
+			name:  "daily at 09:00 rolls to next day",
+			expr:  "0 9 * * *",
+			after: "2026-01-01T09:00:00Z",
+			want:  "2026-01-02T09:00:00Z",
+		},
+		{
+			name:  "crosses a month boundary",
+			expr:  "0 9 1 * *",
+			after: "2026-01-15T00:00:00Z",
+			want:  "2026-02-01T09:00:00Z",
+		},
+		{
+			name:  "weekday-only schedule skips the weekend",
+			expr:  "0 9 * * 1-5",
+			after: "2026-01-02T09:00:00Z", // Friday
+			want:  "2026-01-05T09:00:00Z", // Monday
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			schedule, err := Parse(c.expr)
+			if err != nil {
+				t.Fatalf("Parse(%q): %s", c.expr, err)
+			}
+
+			after, err := time.Parse(time.RFC3339, c.after)
+			if err != nil {
+				t.Fatalf("invalid `after` fixture: %s", err)
+			}
+
+			want, err := time.Parse(time.RFC3339, c.want)
+			if err != nil {
+				t.Fatalf("invalid `want` fixture: %s", err)
+			}
+
+			got := schedule.Next(after.UTC())
+			if !got.Equal(want) {
+				t.Fatalf("Next(%s) = %s, want %s", after, got, want)
+			}
+		})
+	}
+}
@@ -1,21 +1,30 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"text/tabwriter"
 	"time"
 
 	"github.com/docopt/docopt-go"
 	"github.com/kovetskiy/ko"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/seletskiy/cake/calendar"
+	"github.com/seletskiy/cake/confluence"
+	"github.com/seletskiy/cake/metrics"
+	"github.com/seletskiy/cake/notify"
+	"github.com/seletskiy/cake/task"
 )
 
 const usage = `cake - confluence schedule table reader.
@@ -63,6 +72,7 @@ Usage:
     cake -h | --help
     cake [options] (--login= --password=|[--config=]) (--id=|--url=) -D [--listen=]
     cake [options] (--login= --password=|[--config=]) (--id=|--url=) -L [-jc]
+    cake [options] (--login= --password=|[--config=]) (--id=|--url=) --digest-now
 
 Options:
     -h --help              Show this help.
@@ -72,6 +82,9 @@ Options:
     -D                     Run in daemon mode and serve schedules by HTTP.
       --listen=<address>   Listen address and port for daemon mode.
                             [default: :8080]
+    --digest-now           Render and send the duty digest email immediately
+                            and exit, instead of waiting for the configured
+                            cron tick.
     --url=<url>            Confluence URL to get data from. See more about
                             format above.
     --login=<login>        Confluence user login.
@@ -84,6 +97,32 @@ Options:
                             * url.template - URL with two placeholders, "%s",
                               which will be replaced with host and article
                               ID respectfully.
+                            * slack.token - Slack bot token, enables the
+                              on-duty notifier in daemon mode;
+                            * slack.channel - channel to announce duty in;
+                            * slack.cron - 5-field cron expression for the
+                              notification schedule;
+                            * slack.template - text/template for the
+                              notification message.
+                            * smtp.host, smtp.port, smtp.user,
+                              smtp.password - outgoing mail server used for
+                              the duty digest;
+                            * smtp.starttls, smtp.insecure_skip_verify -
+                              STARTTLS options for the SMTP connection;
+                            * digest.cron - 5-field cron expression for the
+                              digest schedule;
+                            * digest.window - how far ahead the digest
+                              looks, e.g. "168h" for a week;
+                            * digest.recipients - list of e-mails to send
+                              the digest to;
+                            * digest.subject - subject line of the digest
+                              e-mail, sent as is (it is not expanded as a
+                              template).
+                            * cache.refresh_interval - how often the daemon
+                              re-validates the cached Confluence page in
+                              the background, e.g. "5m";
+                            * cache.refresh_jitter - random slack added to
+                              refresh_interval, e.g. "30s".
                             [default: $HOME/.config/cake.conf]
 `
 
@@ -94,6 +133,30 @@ type config struct {
 		Host     string `required:"true"`
 		Template string `default:"http://%s/rest/api/content/%s"`
 	}
+	Slack struct {
+		Token    string
+		Channel  string
+		Cron     string `default:"0 9 * * *"`
+		Template string `default:"Today's duty: @{{.SlackShort}}"`
+	}
+	SMTP struct {
+		Host               string
+		Port               int `default:"587"`
+		User               string
+		Password           string
+		STARTTLS           bool
+		InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	}
+	Digest struct {
+		Cron       string `default:"0 9 * * 1"`
+		Window     string `default:"168h"`
+		Recipients []string
+		Subject    string `default:"Upcoming duty schedule"`
+	}
+	Cache struct {
+		RefreshInterval string `toml:"refresh_interval" default:"5m"`
+		RefreshJitter   string `toml:"refresh_jitter" default:"30s"`
+	}
 }
 
 type duty struct {
@@ -113,6 +176,55 @@ type master struct {
 	duty       []duty
 }
 
+// scheduleCache memoizes the parsed schedule, keyed off of the Confluence
+// page body it was parsed from and the day it was parsed on (the Current
+// flag and Today depend on today's date). This avoids re-running the
+// regex-based parser on every daemon request when the page itself is being
+// served from confluenceClient's own cache.
+type scheduleCache struct {
+	client *confluence.Client
+
+	mu      sync.RWMutex
+	page    string
+	day     string
+	masters []master
+}
+
+func newScheduleCache(client *confluence.Client) *scheduleCache {
+	return &scheduleCache{client: client}
+}
+
+func (cache *scheduleCache) Get(ctx context.Context) ([]master, error) {
+	page, _, err := cache.client.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	today := time.Now().Format("2006-01-02")
+
+	cache.mu.RLock()
+	cached := cache.masters
+	fresh := cached != nil && cache.page == page && cache.day == today
+	cache.mu.RUnlock()
+
+	if fresh {
+		return cached, nil
+	}
+
+	masters, err := parseMastersSchedule(page)
+	if err != nil {
+		return nil, err
+	}
+
+	cache.mu.Lock()
+	cache.page = page
+	cache.day = today
+	cache.masters = masters
+	cache.mu.Unlock()
+
+	return masters, nil
+}
+
 func main() {
 	args, err := docopt.Parse(
 		strings.Replace(usage, "$HOME", os.ExpandEnv(`$HOME`), -1),
@@ -149,26 +261,119 @@ func main() {
 		articleURL = fmt.Sprintf(config.URL.Template, config.URL.Host, id)
 	}
 
-	confluencePage, err := getConfluencePage(
-		articleURL,
-		config.Login,
-		config.Password,
-	)
+	confluenceClient := confluence.NewClient(articleURL, config.Login, config.Password)
+	schedule := newScheduleCache(confluenceClient)
 
-	if err != nil {
-		panic(err)
+	var masters []master
+
+	fetchSchedule := func(ctx context.Context) ([]notify.Master, error) {
+		masters, err := schedule.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		notifyMasters := make([]notify.Master, len(masters))
+		for i, master := range masters {
+			notifyMasters[i] = notify.Master{
+				Name:       master.Name,
+				Email:      master.Email,
+				SlackShort: master.SlackShort,
+				Current:    master.Current,
+			}
+		}
+
+		return notifyMasters, nil
 	}
 
-	var masters []master
+	fetchDutyEntries := func(ctx context.Context) ([]task.DutyEntry, error) {
+		masters, err := schedule.Get(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		var entries []task.DutyEntry
+		for _, master := range masters {
+			for _, d := range master.duty {
+				date, err := time.Parse("2006-01-02", d.Date)
+				if err != nil {
+					continue
+				}
+
+				entries = append(entries, task.DutyEntry{
+					Name:  master.Name,
+					Email: master.Email,
+					Date:  date,
+				})
+			}
+		}
+
+		return entries, nil
+	}
+
+	newDigestJob := func() (*task.DigestJob, error) {
+		window, err := time.ParseDuration(config.Digest.Window)
+		if err != nil {
+			return nil, fmt.Errorf("can't parse digest.window: %s", err)
+		}
+
+		return &task.DigestJob{
+			SMTP: task.SMTPConfig{
+				Host:               config.SMTP.Host,
+				Port:               config.SMTP.Port,
+				User:               config.SMTP.User,
+				Password:           config.SMTP.Password,
+				STARTTLS:           config.SMTP.STARTTLS,
+				InsecureSkipVerify: config.SMTP.InsecureSkipVerify,
+			},
+			Window:     window,
+			Recipients: config.Digest.Recipients,
+			Subject:    config.Digest.Subject,
+			Schedule:   fetchDutyEntries,
+		}, nil
+	}
+
+	if args["--digest-now"].(bool) {
+		digestJob, err := newDigestJob()
+		if err != nil {
+			log.Fatalf(`can't configure digest job: %s`, err)
+		}
+
+		err = digestJob.Run(context.Background())
+		if err != nil {
+			log.Fatalf(`can't send digest: %s`, err)
+		}
+
+		return
+	}
 
 	switch {
 	case args["-D"].(bool):
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		refreshInterval, err := time.ParseDuration(config.Cache.RefreshInterval)
+		if err != nil {
+			log.Fatalf(`can't parse cache.refresh_interval: %s`, err)
+		}
+
+		refreshJitter, err := time.ParseDuration(config.Cache.RefreshJitter)
+		if err != nil {
+			log.Fatalf(`can't parse cache.refresh_jitter: %s`, err)
+		}
+
+		go confluenceClient.Refresh(ctx, refreshInterval, refreshJitter)
+
+		http.Handle("/metrics", promhttp.Handler())
+
 		http.HandleFunc(
 			"/",
 			func(writer http.ResponseWriter, request *http.Request) {
-				masters, err = parseMastersSchedule(confluencePage)
+				var err error
+
+				masters, err = schedule.Get(request.Context())
 				if err != nil {
-					log.Print(err)
+					http.Error(writer, err.Error(), http.StatusBadGateway)
+					return
 				}
 
 				var mastersJSON []byte
@@ -184,14 +389,138 @@ func main() {
 			},
 		)
 
-		log.Printf("starting server at %s", args["--listen"].(string))
-		err = http.ListenAndServe(args["--listen"].(string), nil)
-		if err != nil {
-			log.Fatalf(`can's start daemon: %s`, err)
+		http.HandleFunc(
+			"/calendar.ics",
+			func(writer http.ResponseWriter, request *http.Request) {
+				masters, err := schedule.Get(request.Context())
+				if err != nil {
+					http.Error(writer, err.Error(), http.StatusBadGateway)
+					return
+				}
+
+				writeCalendar(writer, masters)
+			},
+		)
+
+		http.HandleFunc(
+			"/calendar/",
+			func(writer http.ResponseWriter, request *http.Request) {
+				slackShort := strings.TrimSuffix(
+					strings.TrimPrefix(request.URL.Path, "/calendar/"),
+					".ics",
+				)
+
+				masters, err := schedule.Get(request.Context())
+				if err != nil {
+					http.Error(writer, err.Error(), http.StatusBadGateway)
+					return
+				}
+
+				var filtered []master
+				for _, candidate := range masters {
+					if candidate.SlackShort == slackShort {
+						filtered = append(filtered, candidate)
+					}
+				}
+
+				writeCalendar(writer, filtered)
+			},
+		)
+
+		if config.Digest.Cron != "" && len(config.Digest.Recipients) > 0 {
+			digestJob, err := newDigestJob()
+			if err != nil {
+				log.Fatalf(`can't configure digest job: %s`, err)
+			}
+
+			scheduler := task.NewScheduler()
+
+			err = scheduler.AddJob("duty-digest", config.Digest.Cron, digestJob.Run)
+			if err != nil {
+				log.Fatalf(`can't schedule digest job: %s`, err)
+			}
+
+			go scheduler.Run(ctx)
+		}
+
+		if config.Slack.Token != "" {
+			slackNotifier := notify.NewSlackNotifier(
+				config.Slack.Token,
+				config.Slack.Channel,
+				config.Slack.Template,
+				fetchSchedule,
+			)
+
+			go func() {
+				err := slackNotifier.Run(ctx, config.Slack.Cron)
+				if err != nil && err != context.Canceled {
+					log.Printf("slack notifier stopped: %s", err)
+				}
+			}()
+
+			http.HandleFunc(
+				"/notify/now",
+				func(writer http.ResponseWriter, request *http.Request) {
+					err := slackNotifier.Send(request.Context())
+					if err != nil {
+						http.Error(writer, err.Error(), http.StatusInternalServerError)
+						return
+					}
+
+					writer.WriteHeader(http.StatusOK)
+				},
+			)
+		}
+
+		server := &http.Server{
+			Addr:              args["--listen"].(string),
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       90 * time.Second,
+		}
+
+		serverErrors := make(chan error, 1)
+
+		go func() {
+			log.Printf("starting server at %s", server.Addr)
+
+			err := server.ListenAndServe()
+			if err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+
+			close(serverErrors)
+		}()
+
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+		select {
+		case err := <-serverErrors:
+			if err != nil {
+				log.Fatalf(`can's start daemon: %s`, err)
+			}
+
+		case sig := <-signals:
+			log.Printf("received %s, shutting down", sig)
+
+			cancel()
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(
+				context.Background(), 10*time.Second,
+			)
+			defer shutdownCancel()
+
+			if err := server.Shutdown(shutdownCtx); err != nil {
+				log.Fatalf(`can't shut down daemon gracefully: %s`, err)
+			}
 		}
 
+		return
+
 	default:
-		masters, err = parseMastersSchedule(confluencePage)
+		masters, err = schedule.Get(context.Background())
 		if err != nil {
 			panic(err)
 		}
@@ -241,40 +570,6 @@ func main() {
 	}
 }
 
-func getConfluencePage(url, login, password string) (string, error) {
-	confluenceRequest, err := http.NewRequest("GET", url+`?expand=body.storage`, nil)
-	if err != nil {
-		panic(err)
-	}
-
-	confluenceRequest.SetBasicAuth(login, password)
-
-	confluenceResponse, err := http.DefaultClient.Do(confluenceRequest)
-	if err != nil {
-		panic(err)
-	}
-
-	articleBodyRaw, err := ioutil.ReadAll(confluenceResponse.Body)
-	if err != nil {
-		panic(err)
-	}
-
-	article := struct {
-		Body struct {
-			Storage struct {
-				Value string
-			}
-		}
-	}{}
-
-	err = json.Unmarshal(articleBodyRaw, &article)
-	if err != nil {
-		panic(err)
-	}
-
-	return article.Body.Storage.Value, nil
-}
-
 func parseMastersSchedule(confluencePage string) ([]master, error) {
 	const (
 		parserStateBegin = iota
@@ -385,16 +680,26 @@ func parseMastersSchedule(confluencePage string) ([]master, error) {
 			}
 
 		case parserStateDay:
-			day, _ := strconv.Atoi(line)
+			day, err := strconv.Atoi(line)
+			if err != nil {
+				metrics.ParserErrorsTotal.WithLabelValues("parserStateDay").Inc()
+				state = parserStateSchedule
+				continue
+			}
+
+			parsedMonth, ok := months[strings.ToLower(month)]
+			if !ok {
+				metrics.ParserErrorsTotal.WithLabelValues("parserStateDay").Inc()
+			}
 
 			date := time.Date(
-				time.Now().Year(), months[strings.ToLower(month)], day,
+				time.Now().Year(), parsedMonth, day,
 				0, 0, 0, 0,
 				time.Local,
 			).Format("2006-01-02")
 
 			if time.Now().Day() == day {
-				if months[strings.ToLower(month)] == time.Now().Month() {
+				if parsedMonth == time.Now().Month() {
 					master.Current = true
 					master.Today = duty{
 						Month: month,
@@ -414,9 +719,47 @@ func parseMastersSchedule(confluencePage string) ([]master, error) {
 		}
 	}
 
+	metrics.MastersTotal.Set(float64(len(masters)))
+
+	metrics.OnDuty.Reset()
+	for _, reportedMaster := range masters {
+		onDuty := 0.0
+		if reportedMaster.Current {
+			onDuty = 1.0
+		}
+
+		metrics.OnDuty.WithLabelValues(reportedMaster.Name, reportedMaster.Email).Set(onDuty)
+	}
+
 	return masters, nil
 }
 
+func writeCalendar(writer http.ResponseWriter, masters []master) {
+	var events []calendar.Event
+
+	for _, dutyMaster := range masters {
+		for _, dutyDate := range dutyMaster.duty {
+			date, err := time.Parse("2006-01-02", dutyDate.Date)
+			if err != nil {
+				continue
+			}
+
+			events = append(events, calendar.Event{
+				Name:  dutyMaster.Name,
+				Email: dutyMaster.Email,
+				Date:  date,
+			})
+		}
+	}
+
+	writer.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+
+	_, err := writer.Write([]byte(calendar.Render(events)))
+	if err != nil {
+		log.Printf("can't write calendar response: %s", err)
+	}
+}
+
 func printDutyTable(masters []master, writer io.Writer) {
 	for _, master := range masters {
 		currentFlag := ""
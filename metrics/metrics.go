@@ -0,0 +1,65 @@
+// Package metrics defines the Prometheus collectors cake exposes at
+// /metrics: Confluence fetch health, parser error counts, and the current
+// on-duty state.
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	// FetchesTotal counts Confluence page fetches, split by HTTP status (or
+	// "error" when the request itself failed before a status was received).
+	FetchesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cake_confluence_fetches_total",
+			Help: "Total number of Confluence page fetches, by HTTP status.",
+		},
+		[]string{"status"},
+	)
+
+	// FetchDuration tracks how long Confluence page fetches take.
+	FetchDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name: "cake_confluence_fetch_duration_seconds",
+			Help: "Latency of Confluence page fetches, in seconds.",
+		},
+	)
+
+	// ParserErrorsTotal counts schedule parser errors, broken down by the
+	// parser state active when the error was encountered.
+	ParserErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cake_parser_errors_total",
+			Help: "Total number of schedule parser errors, by parser state.",
+		},
+		[]string{"state"},
+	)
+
+	// MastersTotal is the number of masters found in the most recently
+	// parsed schedule.
+	MastersTotal = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "cake_masters_total",
+			Help: "Number of masters found in the last parsed schedule.",
+		},
+	)
+
+	// OnDuty is 1 for the master currently on duty and 0 for everyone else,
+	// as of the most recently parsed schedule.
+	OnDuty = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "cake_on_duty",
+			Help: "1 if this master is currently on duty, 0 otherwise.",
+		},
+		[]string{"name", "email"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		FetchesTotal,
+		FetchDuration,
+		ParserErrorsTotal,
+		MastersTotal,
+		OnDuty,
+	)
+}
@@ -0,0 +1,209 @@
+// Package notify implements a scheduled Slack notifier that announces the
+// current on-duty master both in a shared channel and via a direct
+// message.
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/seletskiy/cake/cron"
+)
+
+// Master is the minimal view of a duty record the notifier needs: just
+// enough of package main's `master` type to render and send a message,
+// passed in by the caller to avoid an import cycle with package main.
+type Master struct {
+	Name       string
+	Email      string
+	SlackShort string
+	Current    bool
+}
+
+// ScheduleFunc returns the current schedule, re-parsed from Confluence, so
+// that the notifier always announces a fresh on-duty master.
+type ScheduleFunc func(ctx context.Context) ([]Master, error)
+
+// SlackNotifier periodically posts the current on-duty master to a Slack
+// channel and DMs them directly.
+type SlackNotifier struct {
+	Token    string
+	Channel  string
+	Template string
+
+	schedule ScheduleFunc
+	client   *http.Client
+}
+
+// NewSlackNotifier creates a notifier that posts to `channel` using `token`,
+// rendering messages with `tmpl` (a text/template referring to the fields
+// of Master) and obtaining the schedule from `schedule`.
+func NewSlackNotifier(token, channel, tmpl string, schedule ScheduleFunc) *SlackNotifier {
+	return &SlackNotifier{
+		Token:    token,
+		Channel:  channel,
+		Template: tmpl,
+		schedule: schedule,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Run blocks, sending a notification every time cronExpr matches, until ctx
+// is cancelled. The schedule is re-fetched immediately before every send,
+// so a notification fired right after midnight always reflects the correct
+// day.
+func (notifier *SlackNotifier) Run(ctx context.Context, cronExpr string) error {
+	schedule, err := cron.Parse(cronExpr)
+	if err != nil {
+		return fmt.Errorf("can't parse notify cron expression: %s", err)
+	}
+
+	for {
+		next := schedule.Next(time.Now())
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+
+		case <-timer.C:
+			if err := notifier.Send(ctx); err != nil {
+				log.Printf("notify: %s", err)
+			}
+		}
+	}
+}
+
+// Send re-parses the schedule and posts the notification immediately,
+// regardless of the cron schedule. It backs both the scheduler loop and the
+// `/notify/now` HTTP handler.
+func (notifier *SlackNotifier) Send(ctx context.Context) error {
+	current, err := notifier.currentMaster(ctx)
+	if err != nil {
+		return err
+	}
+
+	text, err := notifier.render(current)
+	if err != nil {
+		return err
+	}
+
+	if err := notifier.postMessage(ctx, notifier.Channel, text); err != nil {
+		return fmt.Errorf("can't post to channel: %s", err)
+	}
+
+	if current.SlackShort != "" {
+		if err := notifier.postMessage(ctx, "@"+current.SlackShort, text); err != nil {
+			return fmt.Errorf("can't DM on-duty master: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// currentMaster re-fetches the schedule with retry/backoff, so that a
+// transient Confluence hiccup right at the moment of a scheduled send
+// doesn't skip the notification entirely.
+func (notifier *SlackNotifier) currentMaster(ctx context.Context) (Master, error) {
+	backoff := time.Second
+
+	var lastErr error
+
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return Master{}, ctx.Err()
+			case <-time.After(backoff):
+			}
+
+			backoff *= 2
+		}
+
+		masters, err := notifier.schedule(ctx)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, master := range masters {
+			if master.Current {
+				return master, nil
+			}
+		}
+
+		lastErr = fmt.Errorf("no master is currently on duty")
+	}
+
+	return Master{}, lastErr
+}
+
+func (notifier *SlackNotifier) render(master Master) (string, error) {
+	tmpl, err := template.New("notify").Parse(notifier.Template)
+	if err != nil {
+		return "", fmt.Errorf("can't parse notify template: %s", err)
+	}
+
+	var text strings.Builder
+
+	if err := tmpl.Execute(&text, master); err != nil {
+		return "", fmt.Errorf("can't render notify template: %s", err)
+	}
+
+	return text.String(), nil
+}
+
+func (notifier *SlackNotifier) postMessage(ctx context.Context, channel, text string) error {
+	form := url.Values{
+		"channel": {channel},
+		"text":    {text},
+	}
+
+	request, err := http.NewRequestWithContext(
+		ctx, "POST",
+		"https://slack.com/api/chat.postMessage",
+		strings.NewReader(form.Encode()),
+	)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Authorization", "Bearer "+notifier.Token)
+
+	response, err := notifier.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack API returned status %s", response.Status)
+	}
+
+	// chat.postMessage reports most failures (bad token, unknown channel,
+	// rate limiting, ...) as HTTP 200 with `"ok": false` in the body.
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&result); err != nil {
+		return fmt.Errorf("can't decode slack API response: %s", err)
+	}
+
+	if !result.OK {
+		return fmt.Errorf("slack API returned an error: %s", result.Error)
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package task
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"html/template"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	textTemplate "text/template"
+	"time"
+)
+
+// DutyEntry is a single day of duty, as needed to render a digest. Package
+// task has no business depending on package main's `master`/`duty` types,
+// so it keeps its own flat view of the fields it actually uses.
+type DutyEntry struct {
+	Name  string
+	Email string
+	Date  time.Time
+}
+
+// ScheduleFunc returns every known duty entry, re-parsed from Confluence.
+// DigestJob filters the result down to the configured window itself.
+type ScheduleFunc func(ctx context.Context) ([]DutyEntry, error)
+
+// SMTPConfig holds the outgoing mail server settings used to send digests.
+type SMTPConfig struct {
+	Host               string
+	Port               int
+	User               string
+	Password           string
+	STARTTLS           bool
+	InsecureSkipVerify bool
+}
+
+// digestPerson is a single group in the rendered digest: one person and
+// the duty dates that fall within the window, in chronological order.
+type digestPerson struct {
+	Name  string
+	Email string
+	Dates []time.Time
+}
+
+// DigestJob collects all duty entries falling within a window starting now
+// and renders/sends an HTML+text summary grouped by person.
+type DigestJob struct {
+	SMTP       SMTPConfig
+	Window     time.Duration
+	Recipients []string
+	Subject    string
+
+	Schedule ScheduleFunc
+}
+
+// Run implements Job: it fetches the schedule, builds the digest, and sends
+// it to the configured recipients. It's also called directly by the
+// `--digest-now` CLI flag so ops can preview the output on demand. When no
+// duty falls within the window, nothing is sent and nil is returned.
+func (job *DigestJob) Run(ctx context.Context) error {
+	entries, err := job.Schedule(ctx)
+	if err != nil {
+		return fmt.Errorf("can't fetch schedule: %s", err)
+	}
+
+	people := job.group(entries)
+	if len(people) == 0 {
+		return nil
+	}
+
+	text, html, err := job.render(people)
+	if err != nil {
+		return err
+	}
+
+	return job.send(job.Subject, text, html)
+}
+
+func (job *DigestJob) group(entries []DutyEntry) []digestPerson {
+	now := time.Now()
+	until := now.Add(job.Window)
+
+	byPerson := map[string]*digestPerson{}
+	var order []string
+
+	for _, entry := range entries {
+		if entry.Date.Before(now) || entry.Date.After(until) {
+			continue
+		}
+
+		// Email is optional in the schedule (see main.go usage doc), so it
+		// can't be used alone as the grouping key: every master without one
+		// would otherwise collapse into the same "" bucket.
+		key := entry.Name + "|" + entry.Email
+
+		person, ok := byPerson[key]
+		if !ok {
+			person = &digestPerson{Name: entry.Name, Email: entry.Email}
+			byPerson[key] = person
+			order = append(order, key)
+		}
+
+		person.Dates = append(person.Dates, entry.Date)
+	}
+
+	sort.Strings(order)
+
+	people := make([]digestPerson, 0, len(order))
+	for _, key := range order {
+		person := *byPerson[key]
+
+		sort.Slice(person.Dates, func(i, j int) bool {
+			return person.Dates[i].Before(person.Dates[j])
+		})
+
+		people = append(people, person)
+	}
+
+	return people
+}
+
+const digestTextTemplate = `Duty digest for the next {{.Window}}:
+{{range .People}}
+{{.Name}} <{{.Email}}>
+{{range .Dates}}  - {{.Format "2006-01-02"}}
+{{end}}{{end}}`
+
+const digestHTMLTemplate = `<html><body>
+<p>Duty digest for the next {{.Window}}:</p>
+{{range .People}}
+<p><b>{{.Name}}</b> &lt;{{.Email}}&gt;</p>
+<ul>{{range .Dates}}<li>{{.Format "2006-01-02"}}</li>{{end}}</ul>
+{{end}}
+</body></html>`
+
+func (job *DigestJob) render(people []digestPerson) (text, html string, err error) {
+	data := struct {
+		Window string
+		People []digestPerson
+	}{
+		Window: job.Window.String(),
+		People: people,
+	}
+
+	parsedText, err := textTemplate.New("digest").Parse(digestTextTemplate)
+	if err != nil {
+		return "", "", fmt.Errorf("can't parse digest template: %s", err)
+	}
+
+	var textOut strings.Builder
+	if err := parsedText.Execute(&textOut, data); err != nil {
+		return "", "", fmt.Errorf("can't render digest template: %s", err)
+	}
+
+	// Names come from a Confluence page that anyone can edit, so the HTML
+	// part must go through html/template to escape them: text/template
+	// would insert them verbatim and let a stray "<"/"&" break the markup
+	// every recipient's mail client renders.
+	parsedHTML, err := template.New("digest").Parse(digestHTMLTemplate)
+	if err != nil {
+		return "", "", fmt.Errorf("can't parse digest template: %s", err)
+	}
+
+	var htmlOut strings.Builder
+	if err := parsedHTML.Execute(&htmlOut, data); err != nil {
+		return "", "", fmt.Errorf("can't render digest template: %s", err)
+	}
+
+	return textOut.String(), htmlOut.String(), nil
+}
+
+func (job *DigestJob) send(subject, text, html string) error {
+	addr := net.JoinHostPort(job.SMTP.Host, fmt.Sprintf("%d", job.SMTP.Port))
+
+	var auth smtp.Auth
+	if job.SMTP.User != "" {
+		auth = smtp.PlainAuth("", job.SMTP.User, job.SMTP.Password, job.SMTP.Host)
+	}
+
+	boundary := "cake-digest-boundary"
+
+	var message strings.Builder
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(job.Recipients, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&message, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", boundary)
+
+	fmt.Fprintf(&message, "--%s\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s\r\n", boundary, text)
+	fmt.Fprintf(&message, "--%s\r\nContent-Type: text/html; charset=utf-8\r\n\r\n%s\r\n", boundary, html)
+	fmt.Fprintf(&message, "--%s--\r\n", boundary)
+
+	if !job.SMTP.STARTTLS {
+		return smtp.SendMail(addr, auth, job.SMTP.User, job.Recipients, []byte(message.String()))
+	}
+
+	return job.sendSTARTTLS(addr, auth, message.String())
+}
+
+func (job *DigestJob) sendSTARTTLS(addr string, auth smtp.Auth, message string) error {
+	client, err := smtp.Dial(addr)
+	if err != nil {
+		return fmt.Errorf("can't connect to SMTP server: %s", err)
+	}
+	defer client.Close()
+
+	tlsConfig := &tls.Config{
+		ServerName:         job.SMTP.Host,
+		InsecureSkipVerify: job.SMTP.InsecureSkipVerify,
+	}
+
+	if err := client.StartTLS(tlsConfig); err != nil {
+		return fmt.Errorf("can't start TLS: %s", err)
+	}
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("can't authenticate: %s", err)
+		}
+	}
+
+	if err := client.Mail(job.SMTP.User); err != nil {
+		return err
+	}
+
+	for _, recipient := range job.Recipients {
+		if err := client.Rcpt(recipient); err != nil {
+			return err
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+
+	if _, err := writer.Write([]byte(message)); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
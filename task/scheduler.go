@@ -0,0 +1,83 @@
+// Package task implements a small cron-like scheduler used by daemon-mode
+// background jobs (digest emails, and friends to come) that need to run on
+// a recurring schedule rather than per HTTP request.
+package task
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/seletskiy/cake/cron"
+)
+
+// Job is a unit of scheduled work. It receives the context passed to
+// Scheduler.Run, cancelled on shutdown.
+type Job func(ctx context.Context) error
+
+type scheduledJob struct {
+	name     string
+	schedule *cron.Schedule
+	run      Job
+}
+
+// Scheduler runs a set of jobs, each on its own cron schedule, until its
+// context is cancelled.
+type Scheduler struct {
+	jobs []scheduledJob
+}
+
+// NewScheduler returns an empty Scheduler. Jobs are registered with
+// AddJob before calling Run.
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// AddJob registers `run` to be invoked every time cronExpr matches. name is
+// used only for logging.
+func (scheduler *Scheduler) AddJob(name, cronExpr string, run Job) error {
+	schedule, err := cron.Parse(cronExpr)
+	if err != nil {
+		return fmt.Errorf("can't parse cron expression for job %q: %s", name, err)
+	}
+
+	scheduler.jobs = append(scheduler.jobs, scheduledJob{
+		name:     name,
+		schedule: schedule,
+		run:      run,
+	})
+
+	return nil
+}
+
+// Run blocks, dispatching each registered job on its own schedule, until ctx
+// is cancelled.
+func (scheduler *Scheduler) Run(ctx context.Context) {
+	for _, job := range scheduler.jobs {
+		go scheduler.runJob(ctx, job)
+	}
+
+	<-ctx.Done()
+}
+
+func (scheduler *Scheduler) runJob(ctx context.Context, job scheduledJob) {
+	for {
+		next := job.schedule.Next(time.Now())
+
+		timer := time.NewTimer(time.Until(next))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+
+		case <-timer.C:
+			log.Printf("task: running job %q", job.name)
+
+			if err := job.run(ctx); err != nil {
+				log.Printf("task: job %q failed: %s", job.name, err)
+			}
+		}
+	}
+}